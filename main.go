@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
 	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/dadosjusbr/coletores"
 	"github.com/dadosjusbr/remuneracao-magistrados/models"
 	"github.com/dadosjusbr/storage"
 	"github.com/joho/godotenv"
@@ -18,6 +24,9 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type config struct {
@@ -30,9 +39,28 @@ type config struct {
 	MongoDBName string `envconfig:"MONGODB_NAME"`
 	MongoMICol  string `envconfig:"MONGODB_MICOL" required:"true"`
 	MongoAgCol  string `envconfig:"MONGODB_AGCOL" required:"true"`
+	MongoAnCol  string `envconfig:"MONGODB_ANCOL"` // Optional, annotations are not returned when empty.
+
+	// Token maintainers use to write annotations. Optional, annotations can not be written when empty.
+	AnnotationsToken string `envconfig:"ANNOTATIONS_TOKEN"`
 
 	// Omited fields
 	EnvOmittedFields []string `envconfig:"ENV_OMITTED_FIELDS"`
+
+	// Agencies which only have aggregated statistics published (no individual rows). Each entry is either
+	// an agency ID or an agency ID and a period, like "trt13:2019-01:2020-12". A period end can be left empty.
+	AggregateOnlyAgencies []string `envconfig:"AGGREGATE_ONLY_AGENCIES"`
+	// Smallest group of employees whose statistics are published by aggregate-only agencies, so no individual income can be deduced.
+	AggregateMinGroupSize int `envconfig:"AGGREGATE_MIN_GROUP_SIZE" default:"5"`
+
+	// Historical agency IDs (renamed or merged agencies) mapped to the canonical agency ID. Each entry is either
+	// "old:new" or "old:new:YYYY-MM", where YYYY-MM is the first month under the new ID.
+	AgencyAliases []string `envconfig:"AGENCY_ALIASES"`
+
+	// How CPFs found in employee data are published: "none" (as they are), "mask" or "hash" (salted with CPFSalt).
+	// Packages contain the raw data, so they are not published unless the policy is "none".
+	CPFPolicy string `envconfig:"CPF_POLICY" default:"none"`
+	CPFSalt   string `envconfig:"CPF_SALT"`
 }
 
 var client *storage.Client
@@ -54,33 +82,145 @@ func newClient(c config) (*storage.Client, error) {
 	return client, nil
 }
 
+// dbTimeout bounds every operation made through the direct DB connection.
+const dbTimeout = 30 * time.Second
+
+var mgoClient *mongo.Client
+var monthlyInfoCol *mongo.Collection
+var annotationsCol *mongo.Collection
+
+// newMongoClient connects to the DB of the storage client. The storage client does not expose its connection, so the
+// queries it does not offer (all employees of a year and the annotations) use this one, which must be closed with
+// closeMongoClient.
+func newMongoClient(c config) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	mgoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(c.MongoURI))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to DB: %q", err)
+	}
+	if err := mgoClient.Ping(ctx, nil); err != nil {
+		mgoClient.Disconnect(ctx)
+		return nil, fmt.Errorf("error connecting to DB: %q", err)
+	}
+	return mgoClient, nil
+}
+
+// closeMongoClient disconnects from the DB, if connected.
+func closeMongoClient() {
+	if mgoClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if err := mgoClient.Disconnect(ctx); err != nil {
+		log.Printf("error disconnecting from DB: %q", err)
+	}
+}
+
+// getAnnotations returns the annotations of an agency/month, including the ones of its historical IDs, sorted by time.
+func getAnnotations(month int, year int, canonicalID string) ([]models.Annotation, error) {
+	if annotationsCol == nil {
+		return nil, nil
+	}
+	filter := bson.D{
+		{Key: "aid", Value: bson.D{{Key: "$in", Value: historicalAgencyIDs(canonicalID, month, year)}}},
+		{Key: "year", Value: year},
+		{Key: "month", Value: month},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	cursor, err := annotationsCol.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "ts", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error searching annotations: %q", err)
+	}
+	var annotations []models.Annotation
+	if err := cursor.All(ctx, &annotations); err != nil {
+		return nil, fmt.Errorf("error decoding annotations: %q", err)
+	}
+	return annotations, nil
+}
+
+func addAnnotation(c echo.Context) error {
+	year, err := strconv.Atoi(c.Param("ano"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d inválido", year))
+	}
+	month, err := strconv.Atoi(c.Param("mes"))
+	if err != nil || month < 1 || month > 12 {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro mês=%d inválido", month))
+	}
+	agencyName := canonicalAgencyID(c.Param("orgao"))
+	if _, err := client.Db.GetAgency(agencyName); err != nil {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro orgao=%s inválido", agencyName))
+	}
+	var body struct {
+		Text string
+	}
+	if err := c.Bind(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+		return c.JSON(http.StatusBadRequest, "O texto da anotação (Text) é obrigatório")
+	}
+	annotation := models.Annotation{
+		AgencyID:  agencyName,
+		Year:      year,
+		Month:     month,
+		Text:      strings.TrimSpace(body.Text),
+		Timestamp: time.Now().UTC(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if _, err := annotationsCol.InsertOne(ctx, annotation); err != nil {
+		log.Printf("[add annotation] error storing annotation(mes:%d ano:%d, orgao:%s):%q", month, year, agencyName, err)
+		return c.JSON(http.StatusInternalServerError, "Erro armazenando anotação")
+	}
+	return c.JSON(http.StatusCreated, annotation)
+}
+
 func getTotalsOfAgencyYear(c echo.Context) error {
 	year, err := strconv.Atoi(c.Param("ano"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d inválido", year))
 	}
-	aID := c.Param("orgao")
-	agenciesMonthlyInfo, err := client.Db.GetMonthlyInfo([]storage.Agency{{ID: aID}}, year)
+	aID := canonicalAgencyID(c.Param("orgao"))
+	// IDs are only valid until a month, so the ones valid in January are all the ones which might hold data in the year.
+	aIDs := historicalAgencyIDs(aID, 1, year)
+	var agencies []storage.Agency
+	for _, id := range aIDs {
+		agencies = append(agencies, storage.Agency{ID: id})
+	}
+	agenciesMonthlyInfo, err := client.Db.GetMonthlyInfo(agencies, year)
 	if err != nil {
 		log.Printf("[totals of agency year] error getting data for first screen(ano:%d, estado:%s):%q", year, aID, err)
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d ou orgao=%s inválidos", year, aID))
 	}
-	var monthTotalsOfYear []models.MonthTotals
 	agency, err := client.Db.GetAgency(aID)
 	if err != nil {
 		log.Printf("[totals of agency year] error getting data for first screen(estado:%s):%q", aID, err)
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro orgao=%s inválido", aID))
 	}
-	for _, agencyMonthlyInfo := range agenciesMonthlyInfo[aID] {
-		if agencyMonthlyInfo.Summary.MemberActive.Wage.Total+agencyMonthlyInfo.Summary.MemberActive.Perks.Total+agencyMonthlyInfo.Summary.MemberActive.Others.Total > 0 {
-			monthTotals := models.MonthTotals{Month: agencyMonthlyInfo.Month,
-				Wage:   agencyMonthlyInfo.Summary.MemberActive.Wage.Total,
-				Perks:  agencyMonthlyInfo.Summary.MemberActive.Perks.Total,
-				Others: agencyMonthlyInfo.Summary.MemberActive.Others.Total,
+	// Months of merged agencies are summed up, so the time series of the canonical agency is not split.
+	monthTotalsByMonth := make(map[int]*models.MonthTotals)
+	for _, id := range aIDs {
+		for _, agencyMonthlyInfo := range agenciesMonthlyInfo[id] {
+			if !aliasValidAt(id, agencyMonthlyInfo.Month, year) {
+				continue
+			}
+			if agencyMonthlyInfo.Summary.MemberActive.Wage.Total+agencyMonthlyInfo.Summary.MemberActive.Perks.Total+agencyMonthlyInfo.Summary.MemberActive.Others.Total > 0 {
+				monthTotals, ok := monthTotalsByMonth[agencyMonthlyInfo.Month]
+				if !ok {
+					monthTotals = &models.MonthTotals{Month: agencyMonthlyInfo.Month}
+					monthTotalsByMonth[agencyMonthlyInfo.Month] = monthTotals
+				}
+				monthTotals.Wage += models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Wage.Total)
+				monthTotals.Perks += models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Perks.Total)
+				monthTotals.Others += models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Others.Total)
 			}
-			monthTotalsOfYear = append(monthTotalsOfYear, monthTotals)
 		}
 	}
+	var monthTotalsOfYear []models.MonthTotals
+	for _, monthTotals := range monthTotalsByMonth {
+		monthTotalsOfYear = append(monthTotalsOfYear, *monthTotals)
+	}
 	sort.Slice(monthTotalsOfYear, func(i, j int) bool {
 		return monthTotalsOfYear[i].Month < monthTotalsOfYear[j].Month
 	})
@@ -88,6 +228,170 @@ func getTotalsOfAgencyYear(c echo.Context) error {
 	return c.JSON(http.StatusOK, agencyTotalsYear)
 }
 
+func getComponentsOfAgencyYear(c echo.Context) error {
+	year, err := strconv.Atoi(c.Param("ano"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d inválido", year))
+	}
+	aID := canonicalAgencyID(c.Param("orgao"))
+	agency, err := client.Db.GetAgency(aID)
+	if err != nil {
+		log.Printf("[components of agency year] error getting agency(orgao:%s):%q", aID, err)
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro orgao=%s inválido", aID))
+	}
+	employeesByMonth, err := getEmployeesOfYear(year, aID)
+	if err != nil {
+		log.Printf("[components of agency year] error getting data(ano:%d, orgao:%s):%q", year, aID, err)
+		return c.JSON(http.StatusInternalServerError, fmt.Sprintf("Erro buscando dados do órgão %s em %d", aID, year))
+	}
+	var monthComponentsOfYear []models.MonthComponents
+	for month := 1; month <= 12; month++ {
+		employees, ok := employeesByMonth[month]
+		if !ok {
+			// That happens when there is no information on that month.
+			continue
+		}
+		monthComponents := sumComponents(month, employees)
+		if monthComponents.Wage+sumValues(monthComponents.Perks)+sumValues(monthComponents.Others) > 0 {
+			monthComponentsOfYear = append(monthComponentsOfYear, monthComponents)
+		}
+	}
+	agencyComponentsYear := models.AgencyComponentsYear{Year: year, MonthComponents: monthComponentsOfYear, AgencyFullName: agency.Name}
+	return c.JSON(http.StatusOK, agencyComponentsYear)
+}
+
+// getEmployeesOfYear fetches in a single query the employees of every month of a year of the canonical agency,
+// including the ones stored under its historical IDs. Months without information are not in the map.
+func getEmployeesOfYear(year int, canonicalID string) (map[int][]coletores.Employee, error) {
+	filter := bson.D{
+		// IDs are only valid until a month, so the ones valid in January are all the ones which might hold data in the year.
+		{Key: "aid", Value: bson.D{{Key: "$in", Value: historicalAgencyIDs(canonicalID, 1, year)}}},
+		{Key: "year", Value: year},
+	}
+	projection := bson.D{{Key: "aid", Value: 1}, {Key: "month", Value: 1}, {Key: "employee", Value: 1}}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	cursor, err := monthlyInfoCol.Find(ctx, filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, fmt.Errorf("error searching employees: %q", err)
+	}
+	var monthlyInfos []storage.AgencyMonthlyInfo
+	if err := cursor.All(ctx, &monthlyInfos); err != nil {
+		return nil, fmt.Errorf("error decoding employees: %q", err)
+	}
+	employeesByMonth := make(map[int][]coletores.Employee)
+	for _, mi := range monthlyInfos {
+		if !aliasValidAt(mi.AgencyID, mi.Month, year) {
+			continue
+		}
+		employeesByMonth[mi.Month] = append(employeesByMonth[mi.Month], mi.Employee...)
+	}
+	return employeesByMonth, nil
+}
+
+// sumComponents sums up each remuneration component of the active members of an agency.
+// Agencies which only publish the total of a group (perks, other funds or discounts) have it, or the part of it
+// which is not itemized, under "unclassified", so the components always add up to the totals of the group.
+func sumComponents(month int, employees []coletores.Employee) models.MonthComponents {
+	mc := models.MonthComponents{
+		Month:              month,
+		Perks:              make(map[string]models.Money),
+		Others:             make(map[string]models.Money),
+		PublishedOthers:    make(map[string]models.Money),
+		Discounts:          make(map[string]models.Money),
+		PublishedDiscounts: make(map[string]models.Money),
+	}
+	for _, e := range employees {
+		if !e.Active || e.Type == nil || *e.Type != "membro" {
+			continue
+		}
+		if e.Income != nil {
+			if e.Income.Wage != nil {
+				mc.Wage += models.NewMoney(*e.Income.Wage)
+			}
+			if p := e.Income.Perks; p != nil {
+				itemized := addComponent(mc.Perks, "food", p.Food) +
+					addComponent(mc.Perks, "vacation", p.Vacations) +
+					addComponent(mc.Perks, "transportation", p.Transportation) +
+					addComponent(mc.Perks, "pre_school", p.PreSchool) +
+					addComponent(mc.Perks, "health", p.Health) +
+					addComponent(mc.Perks, "birth_aid", p.BirthAid) +
+					addComponent(mc.Perks, "housing_aid", p.HousingAid) +
+					addComponent(mc.Perks, "subsistence", p.Subsistence) +
+					addComponent(mc.Perks, "compensatory_leave", p.CompensatoryLeave) +
+					addComponent(mc.Perks, "pecuniary", p.Pecuniary) +
+					addComponent(mc.Perks, "vacation_pecuniary", p.VacationPecuniary) +
+					addComponent(mc.Perks, "furniture_transport", p.FurnitureTransport) +
+					addComponent(mc.Perks, "premium_license_pecuniary", p.PremiumLicensePecuniary)
+				addUnclassified(mc.Perks, p.Total, itemized)
+			}
+			if o := e.Income.Other; o != nil {
+				itemized := addComponent(mc.Others, "personal_benefits", o.PersonalBenefits) +
+					addComponent(mc.Others, "eventual_benefits", o.EventualBenefits) +
+					addComponent(mc.Others, "trust_position", o.PositionOfTrust) +
+					addComponent(mc.Others, "daily", o.Daily) +
+					addComponent(mc.Others, "gratification", o.Gratification) +
+					addComponent(mc.Others, "origin_pos", o.OriginPosition) +
+					addOtherComponents(mc.Others, mc.PublishedOthers, o.Others, o.OtherFundsTotal)
+				addUnclassified(mc.Others, o.Total, itemized)
+			}
+		}
+		if d := e.Discounts; d != nil {
+			itemized := addComponent(mc.Discounts, "prev_contribution", d.PrevContribution) +
+				addComponent(mc.Discounts, "ceil_retention", d.CeilRetention) +
+				addComponent(mc.Discounts, "income_tax", d.IncomeTax) +
+				addOtherComponents(mc.Discounts, mc.PublishedDiscounts, d.Others, d.OtherDiscountsTotal)
+			addUnclassified(mc.Discounts, d.Total, itemized)
+		}
+	}
+	return mc
+}
+
+// addComponent adds the value, if published, to the component. It returns the amount added.
+func addComponent(components map[string]models.Money, name string, value *float64) models.Money {
+	if value == nil {
+		return 0
+	}
+	v := models.NewMoney(*value)
+	components[name] += v
+	return v
+}
+
+// addOtherComponents adds the components that do not have a pattern among the agencies. Their total always
+// goes to components under "others_total". The items, keyed by the names published by the agency, go to
+// published, which is kept apart so they can not be mixed up with the fixed components.
+// It returns the amount added to components.
+func addOtherComponents(components, published map[string]models.Money, others map[string]float64, total *float64) models.Money {
+	var itemsTotal models.Money
+	for name, value := range others {
+		published[name] += models.NewMoney(value)
+		itemsTotal += models.NewMoney(value)
+	}
+	switch {
+	case total != nil:
+		return addComponent(components, "others_total", total)
+	case len(others) > 0:
+		components["others_total"] += itemsTotal
+		return itemsTotal
+	}
+	return 0
+}
+
+// addUnclassified adds to components, under "unclassified", the part of the total of a group which is not itemized.
+func addUnclassified(components map[string]models.Money, total float64, itemized models.Money) {
+	if unclassified := models.NewMoney(total) - itemized; unclassified != 0 {
+		components["unclassified"] += unclassified
+	}
+}
+
+func sumValues(components map[string]models.Money) models.Money {
+	var total models.Money
+	for _, v := range components {
+		total += v
+	}
+	return total
+}
+
 func getBasicInfoOfState(c echo.Context) error {
 	yearOfConsult := time.Now().Year()
 	stateName := c.Param("estado")
@@ -105,6 +409,10 @@ func getBasicInfoOfState(c echo.Context) error {
 	}
 	var agenciesBasic []models.AgencyBasic
 	for k := range agencies {
+		// Historical agencies are presented through their canonical agency.
+		if _, ok := agencyAliases[agencies[k].ID]; ok {
+			continue
+		}
 		agenciesBasic = append(agenciesBasic, models.AgencyBasic{Name: agencies[k].ID, FullName: agencies[k].Name, AgencyCategory: agencies[k].Entity})
 	}
 	state := models.State{Name: stateName, ShortName: "", FlagURL: "", Agency: agenciesBasic}
@@ -120,8 +428,8 @@ func getSalaryOfAgencyMonthYear(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d", year))
 	}
-	agencyName := c.Param("orgao")
-	agencyMonthlyInfo, _, err := client.GetOMA(month, year, agencyName)
+	agencyName := canonicalAgencyID(c.Param("orgao"))
+	agencyMonthlyInfo, _, err := getOMA(month, year, agencyName)
 	if err != nil {
 		log.Printf("[salary agency month year] error getting data for second screen(mes:%d ano:%d, orgao:%s):%q", month, year, agencyName, err)
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d, mês=%d ou nome do orgão=%s são inválidos", year, month, agencyName))
@@ -138,17 +446,28 @@ func getSalaryOfAgencyMonthYear(c echo.Context) error {
 			}
 		}
 		agencyMonthlyInfo.ProcInfo.Env = newEnv
+		// After the parsing stage the process output carries the employees data.
+		if protectingCPFs() {
+			agencyMonthlyInfo.ProcInfo.Stdin = protectCPF(agencyMonthlyInfo.ProcInfo.Stdin)
+			agencyMonthlyInfo.ProcInfo.Stdout = protectCPF(agencyMonthlyInfo.ProcInfo.Stdout)
+			agencyMonthlyInfo.ProcInfo.Stderr = protectCPF(agencyMonthlyInfo.ProcInfo.Stderr)
+		}
 		return c.JSON(http.StatusPartialContent, models.ProcInfoResult{
 			ProcInfo:          agencyMonthlyInfo.ProcInfo,
 			CrawlingTimestamp: agencyMonthlyInfo.CrawlingTimestamp,
 		})
 	}
-	return c.JSON(http.StatusOK, models.DataForChartAtAgencyScreen{
-		Members:     agencyMonthlyInfo.Summary.MemberActive.IncomeHistogram,
-		MaxSalary:   agencyMonthlyInfo.Summary.MemberActive.Wage.Max,
-		PackageURL:  agencyMonthlyInfo.Package.URL,
-		PackageHash: agencyMonthlyInfo.Package.URL,
-	})
+	chartData := models.DataForChartAtAgencyScreen{
+		Members:   agencyMonthlyInfo.Summary.MemberActive.IncomeHistogram,
+		MaxSalary: models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Wage.Max),
+	}
+	// The package contains individual rows and raw CPFs, so it is not linked for aggregate-only agencies
+	// or while CPFs are protected.
+	if !isAggregateOnly(agencyName, month, year) && !protectingCPFs() && agencyMonthlyInfo.Package != nil {
+		chartData.PackageURL = agencyMonthlyInfo.Package.URL
+		chartData.PackageHash = agencyMonthlyInfo.Package.URL
+	}
+	return c.JSON(http.StatusOK, chartData)
 }
 
 func getSummaryOfAgency(c echo.Context) error {
@@ -160,30 +479,69 @@ func getSummaryOfAgency(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro mês=%d", month))
 	}
-	agencyName := c.Param("orgao")
-	agencyMonthlyInfo, agency, err := client.GetOMA(month, year, agencyName)
+	agencyName := canonicalAgencyID(c.Param("orgao"))
+	agencyMonthlyInfo, agency, err := getOMA(month, year, agencyName)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro ano=%d, mês=%d ou nome do orgão=%s são inválidos", year, month, agencyName))
 	}
+	annotations, err := getAnnotations(month, year, agencyName)
+	if err != nil {
+		// Annotations are complementary, so the summary is still returned.
+		log.Printf("[summary of agency] error getting annotations(mes:%d ano:%d, orgao:%s):%q", month, year, agencyName, err)
+	}
+	totalInactives, totalInactiveRemuneration := sumIncomes(agencyMonthlyInfo.Employee, isInactive)
+	totalPensioners, totalPensionerRemuneration := sumIncomes(agencyMonthlyInfo.Employee, isPensioner)
 	agencySummary := models.AgencySummary{
 		FullName:  agency.Name,
-		TotalWage: agencyMonthlyInfo.Summary.MemberActive.Wage.Total,
-		MaxWage:   agencyMonthlyInfo.Summary.MemberActive.Wage.Max,
-		TotalPerks: agencyMonthlyInfo.Summary.MemberActive.Perks.Total +
-			agencyMonthlyInfo.Summary.MemberActive.Others.Total,
-		MaxPerk: math.Max(agencyMonthlyInfo.Summary.MemberActive.Perks.Max, agencyMonthlyInfo.Summary.MemberActive.Others.Max),
-		TotalRemuneration: agencyMonthlyInfo.Summary.MemberActive.Wage.Total +
-			agencyMonthlyInfo.Summary.MemberActive.Perks.Total +
-			agencyMonthlyInfo.Summary.MemberActive.Others.Total +
-			agencyMonthlyInfo.Summary.MemberActive.Wage.Total,
-		TotalMembers: agencyMonthlyInfo.Summary.MemberActive.Count,
-		CrawlingTime: agencyMonthlyInfo.CrawlingTimestamp,
-		HasNext:      verifyNextOMA(month, year, agencyName),
-		HasPrevious:  verifyPreviousOMA(month, year, agencyName),
+		TotalWage: models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Wage.Total),
+		MaxWage:   models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Wage.Max),
+		TotalPerks: models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Perks.Total) +
+			models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Others.Total),
+		MaxPerk: models.NewMoney(math.Max(agencyMonthlyInfo.Summary.MemberActive.Perks.Max, agencyMonthlyInfo.Summary.MemberActive.Others.Max)),
+		TotalRemuneration: models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Wage.Total) +
+			models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Perks.Total) +
+			models.NewMoney(agencyMonthlyInfo.Summary.MemberActive.Others.Total),
+		TotalMembers:               agencyMonthlyInfo.Summary.MemberActive.Count,
+		TotalServants:              agencyMonthlyInfo.Summary.ServantActive.Count,
+		TotalInactives:             totalInactives,
+		TotalInactiveRemuneration:  totalInactiveRemuneration,
+		TotalPensioners:            totalPensioners,
+		TotalPensionerRemuneration: totalPensionerRemuneration,
+		CrawlingTime:               agencyMonthlyInfo.CrawlingTimestamp,
+		HasNext:                    verifyNextOMA(month, year, agencyName),
+		HasPrevious:                verifyPreviousOMA(month, year, agencyName),
+		Annotations:                annotations,
 	}
 	return c.JSON(http.StatusOK, agencySummary)
 }
 
+// sumIncomes counts the employees which match and sums up their income.
+// The stored summaries only cover active members and servants, so the employees are used instead.
+func sumIncomes(employees []coletores.Employee, match func(coletores.Employee) bool) (int, models.Money) {
+	var count int
+	var total models.Money
+	for _, e := range employees {
+		if !match(e) {
+			continue
+		}
+		count++
+		if e.Income != nil {
+			total += models.NewMoney(e.Income.Total)
+		}
+	}
+	return count, total
+}
+
+// isInactive tells whether the employee is an inactive (retired) member or servant.
+func isInactive(e coletores.Employee) bool {
+	return !e.Active && !isPensioner(e)
+}
+
+// isPensioner tells whether the employee is a pensioner. Agencies mark pensioners both as active and inactive.
+func isPensioner(e coletores.Employee) bool {
+	return e.Type != nil && *e.Type == "pensionista"
+}
+
 func verifyNextOMA(month int, year int, agencyName string) bool {
 	if month == 12 {
 		month = 1
@@ -191,7 +549,7 @@ func verifyNextOMA(month int, year int, agencyName string) bool {
 	} else {
 		month += 1
 	}
-	_, _, err := client.GetOMA(month, year, agencyName)
+	_, _, err := getOMA(month, year, agencyName)
 	return err == nil
 }
 func verifyPreviousOMA(month int, year int, agencyName string) bool {
@@ -201,7 +559,7 @@ func verifyPreviousOMA(month int, year int, agencyName string) bool {
 	} else {
 		month -= 1
 	}
-	_, _, err := client.GetOMA(month, year, agencyName)
+	_, _, err := getOMA(month, year, agencyName)
 	return err == nil
 }
 
@@ -214,14 +572,29 @@ func apiOMA(c echo.Context) error {
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, fmt.Sprintf("Parâmetro mês=%d", month))
 	}
-	agName := c.Param("orgao")
-	agMI, _, err := client.GetOMA(month, year, agName)
+	agName := canonicalAgencyID(c.Param("orgao"))
+	agMI, _, err := getOMA(month, year, agName)
 	if err != nil {
 		c.Logger().Printf("Error fetching data for API (%s?%s):%q", c.Path(), c.QueryString(), err)
 		return c.JSON(http.StatusInternalServerError, fmt.Sprintf("Error buscando dados"))
 	}
+	if isAggregateOnly(agName, month, year) {
+		if format := c.QueryParam("format"); format != "json" && format != "" {
+			return c.String(http.StatusBadRequest, fmt.Sprintf("Órgão %s publica apenas dados agregados em %02d/%d. Por favor, escolher o formato json!", agName, month, year))
+		}
+		return c.JSONPretty(http.StatusOK, aggregateSummaries(agMI.Summary, conf.AggregateMinGroupSize), " ")
+	}
+	if protectingCPFs() {
+		protectCPFs(agMI.Employee)
+	}
 	switch format := c.QueryParam("format"); format {
 	case "zip":
+		if protectingCPFs() {
+			return c.String(http.StatusForbidden, fmt.Sprintf("O pacote pode conter CPFs e não é publicado. Por favor, escolher o formato json!"))
+		}
+		if agMI.Package == nil {
+			return c.String(http.StatusNotFound, fmt.Sprintf("Não há pacote disponível para o órgão %s em %02d/%d", agName, month, year))
+		}
 		return c.Redirect(http.StatusPermanentRedirect, agMI.Package.URL)
 	case "json", "":
 		return c.JSONPretty(http.StatusOK, agMI.Employee, " ")
@@ -230,6 +603,344 @@ func apiOMA(c echo.Context) error {
 	}
 }
 
+// cpfRegexp matches CPFs, including fragments partially hidden by the agencies (e.g. ***.456.789-**).
+// CPFs without punctuation are also matched; they are only taken as CPFs if their check digits are valid.
+var cpfRegexp = regexp.MustCompile(`[0-9*]{3}\.[0-9*]{3}\.[0-9*]{3}-[0-9*]{2}|\b[0-9]{11}\b`)
+
+// protectingCPFs tells whether CPFs are masked or hashed before being published.
+func protectingCPFs() bool {
+	return conf.CPFPolicy != "none"
+}
+
+// protectCPFs masks or hashes the CPFs found in the employees data, so personal identifiers are not republished.
+// Hashing keeps a linkage key between months without exposing the CPF.
+func protectCPFs(employees []coletores.Employee) {
+	for i := range employees {
+		employees[i].Reg = protectCPF(employees[i].Reg)
+		employees[i].Name = protectCPF(employees[i].Name)
+		employees[i].Role = protectCPF(employees[i].Role)
+		employees[i].Workplace = protectCPF(employees[i].Workplace)
+	}
+}
+
+func protectCPF(s string) string {
+	return cpfRegexp.ReplaceAllStringFunc(s, func(cpf string) string {
+		// Bare 11 digit numbers might be registration numbers (matrículas).
+		if len(cpf) == 11 && !validCPF(cpf) {
+			return cpf
+		}
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, cpf)
+		if digits == "" {
+			return cpf
+		}
+		if conf.CPFPolicy == "hash" {
+			sum := sha256.Sum256([]byte(conf.CPFSalt + digits))
+			return hex.EncodeToString(sum[:8])
+		}
+		return "***.***.***-**"
+	})
+}
+
+// validCPF checks the two check digits of an 11 digit CPF.
+func validCPF(cpf string) bool {
+	if strings.Count(cpf, cpf[:1]) == len(cpf) {
+		// Numbers like 000.000.000-00 pass the check digits validation, but are not CPFs.
+		return false
+	}
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(cpf[i]-'0') * (n + 1 - i)
+		}
+		check := sum * 10 % 11
+		if check == 10 {
+			check = 0
+		}
+		if check != int(cpf[n]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+// agencyAlias - Change of ID of an agency, which was renamed or merged into another agency
+type agencyAlias struct {
+	canonical string // ID after the change, which might also have changed later.
+	until     int    // First month under the new ID, as months since year 0. Zero means the old ID is valid in every month.
+}
+
+var agencyAliases map[string]agencyAlias
+
+// parseAgencyAliases parses entries like "old:new" or "old:new:2019-01", keyed by the old ID.
+func parseAgencyAliases(entries []string) (map[string]agencyAlias, error) {
+	aliases := make(map[string]agencyAlias)
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, fmt.Errorf("invalid AGENCY_ALIASES entry \"%s\": it must be old:new or old:new:YYYY-MM", entry)
+		}
+		old, alias := parts[0], agencyAlias{canonical: parts[1]}
+		if old == "" || alias.canonical == "" || old == alias.canonical {
+			return nil, fmt.Errorf("invalid AGENCY_ALIASES entry \"%s\": it must have two different agency IDs", entry)
+		}
+		if _, ok := aliases[old]; ok {
+			return nil, fmt.Errorf("invalid AGENCY_ALIASES entry \"%s\": \"%s\" has more than one alias", entry, old)
+		}
+		if len(parts) == 3 {
+			var err error
+			if alias.until, err = parseMonthOfYear(parts[2]); err != nil || alias.until == 0 {
+				return nil, fmt.Errorf("invalid AGENCY_ALIASES entry \"%s\": the month must be YYYY-MM", entry)
+			}
+		}
+		aliases[old] = alias
+	}
+	if err := validateAgencyAliases(aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// validateAgencyAliases makes sure following the aliases always ends at a canonical agency ID.
+func validateAgencyAliases(aliases map[string]agencyAlias) error {
+	for old := range aliases {
+		visited := map[string]bool{old: true}
+		for alias, ok := aliases[old]; ok; alias, ok = aliases[alias.canonical] {
+			if visited[alias.canonical] {
+				return fmt.Errorf("invalid AGENCY_ALIASES: cycle found starting at \"%s\"", old)
+			}
+			visited[alias.canonical] = true
+		}
+	}
+	return nil
+}
+
+// canonicalAgencyID returns the current ID of an agency which might have been renamed or merged.
+// Chained aliases (e.g. a->b and b->c) are followed until the canonical ID.
+func canonicalAgencyID(aID string) string {
+	// Cycles are rejected at startup, so the chain is never longer than the number of aliases.
+	for i := 0; i <= len(agencyAliases); i++ {
+		alias, ok := agencyAliases[aID]
+		if !ok {
+			return aID
+		}
+		aID = alias.canonical
+	}
+	return aID
+}
+
+// aliasValidAt tells whether the data stored under an agency ID in a month belongs to its canonical agency, which
+// happens when the month is before every change of ID between them. Otherwise the month was stored again under
+// the new ID (e.g. crawled after a rename), and counting the old ID would duplicate it.
+func aliasValidAt(aID string, month int, year int) bool {
+	m := year*12 + month
+	for alias, ok := agencyAliases[aID]; ok; alias, ok = agencyAliases[alias.canonical] {
+		if alias.until != 0 && m >= alias.until {
+			return false
+		}
+	}
+	return true
+}
+
+// historicalAgencyIDs returns the canonical agency ID followed by the IDs it had over time which are valid in the month.
+func historicalAgencyIDs(canonicalID string, month int, year int) []string {
+	ids := []string{canonicalID}
+	for old := range agencyAliases {
+		if canonicalAgencyID(old) == canonicalID && aliasValidAt(old, month, year) {
+			ids = append(ids, old)
+		}
+	}
+	sort.Strings(ids[1:])
+	return ids
+}
+
+// getOMA fetches the OMA (órgão/mês/ano) of the canonical agency. The months stored under every historical
+// ID of the agency valid in the month are merged, so merged agencies are presented like the yearly totals present them.
+// The canonical agency is always returned when it is in the catalog.
+func getOMA(month int, year int, canonicalID string) (*storage.AgencyMonthlyInfo, *storage.Agency, error) {
+	var merged *storage.AgencyMonthlyInfo
+	var agency *storage.Agency
+	for _, id := range historicalAgencyIDs(canonicalID, month, year) {
+		agMI, ag, err := client.Db.GetOMA(month, year, id)
+		if err == storage.ErrNothingFound {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("GetOMA() error(orgao:%s): %q", id, err)
+		}
+		if merged == nil {
+			merged, agency = agMI, ag
+			continue
+		}
+		merged = mergeOMAs(merged, agMI)
+	}
+	if merged == nil {
+		return nil, nil, storage.ErrNothingFound
+	}
+	if agency.ID != canonicalID {
+		if canonical, err := client.Db.GetAgency(canonicalID); err == nil {
+			agency = canonical
+		}
+		merged.AgencyID = canonicalID
+	}
+	return merged, agency, nil
+}
+
+// mergeOMAs merges the same month of two agencies which were merged into one.
+// Each agency has its own package, so the merged month has no package.
+func mergeOMAs(a, b *storage.AgencyMonthlyInfo) *storage.AgencyMonthlyInfo {
+	m := *a
+	m.Employee = append(append([]coletores.Employee{}, a.Employee...), b.Employee...)
+	m.Backups = append(append([]storage.Backup{}, a.Backups...), b.Backups...)
+	m.Summary = storage.Summaries{
+		General:       mergeSummary(a.Summary.General, b.Summary.General),
+		MemberActive:  mergeSummary(a.Summary.MemberActive, b.Summary.MemberActive),
+		Undefined:     mergeSummary(a.Summary.Undefined, b.Summary.Undefined),
+		ServantActive: mergeSummary(a.Summary.ServantActive, b.Summary.ServantActive),
+	}
+	m.Package = nil
+	if m.ProcInfo == nil {
+		m.ProcInfo = b.ProcInfo
+	}
+	if b.CrawlingTimestamp.After(m.CrawlingTimestamp) {
+		m.CrawlingTimestamp = b.CrawlingTimestamp
+	}
+	return &m
+}
+
+func mergeSummary(a, b storage.Summary) storage.Summary {
+	hist := make(map[int]int)
+	for k, v := range a.IncomeHistogram {
+		hist[k] += v
+	}
+	for k, v := range b.IncomeHistogram {
+		hist[k] += v
+	}
+	return storage.Summary{
+		Count:           a.Count + b.Count,
+		Wage:            mergeDataSummary(a.Wage, a.Count, b.Wage, b.Count),
+		Perks:           mergeDataSummary(a.Perks, a.Count, b.Perks, b.Count),
+		Others:          mergeDataSummary(a.Others, a.Count, b.Others, b.Count),
+		Benefits:        mergeDataSummary(a.Benefits, a.Count, b.Benefits, b.Count),
+		IncomeHistogram: hist,
+	}
+}
+
+func mergeDataSummary(a storage.DataSummary, aCount int, b storage.DataSummary, bCount int) storage.DataSummary {
+	if aCount == 0 {
+		return b
+	}
+	if bCount == 0 {
+		return a
+	}
+	return storage.DataSummary{
+		Max:     math.Max(a.Max, b.Max),
+		Min:     math.Min(a.Min, b.Min),
+		Average: (a.Average*float64(aCount) + b.Average*float64(bCount)) / float64(aCount+bCount),
+		Total:   a.Total + b.Total,
+	}
+}
+
+// aggregateOnlyRule - An agency, optionally restricted to a period, which only has aggregated statistics published
+type aggregateOnlyRule struct {
+	agency string
+	from   int // First month, as months since year 0. Zero means no start.
+	to     int // Last month, as months since year 0. Zero means no end.
+}
+
+var aggregateOnlyRules []aggregateOnlyRule
+
+// parseAggregateOnlyRules parses entries like "trt13", "trt13:2019-01:2020-12" or "trt13:2019-01:".
+func parseAggregateOnlyRules(entries []string) ([]aggregateOnlyRule, error) {
+	var rules []aggregateOnlyRule
+	for _, entry := range entries {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		rule := aggregateOnlyRule{agency: parts[0]}
+		switch len(parts) {
+		case 1:
+		case 3:
+			var err error
+			if rule.from, err = parseMonthOfYear(parts[1]); err != nil {
+				return nil, fmt.Errorf("invalid AGGREGATE_ONLY_AGENCIES entry \"%s\": %q", entry, err)
+			}
+			if rule.to, err = parseMonthOfYear(parts[2]); err != nil {
+				return nil, fmt.Errorf("invalid AGGREGATE_ONLY_AGENCIES entry \"%s\": %q", entry, err)
+			}
+			if rule.from != 0 && rule.to != 0 && rule.from > rule.to {
+				return nil, fmt.Errorf("invalid AGGREGATE_ONLY_AGENCIES entry \"%s\": the period ends before it starts", entry)
+			}
+		default:
+			return nil, fmt.Errorf("invalid AGGREGATE_ONLY_AGENCIES entry \"%s\": it must be agency or agency:YYYY-MM:YYYY-MM", entry)
+		}
+		if rule.agency == "" {
+			return nil, fmt.Errorf("invalid AGGREGATE_ONLY_AGENCIES entry \"%s\": missing agency", entry)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseMonthOfYear parses a YYYY-MM month into months since year 0. An empty month is returned as zero.
+func parseMonthOfYear(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("2006-01", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Year()*12 + int(t.Month()), nil
+}
+
+// isAggregateOnly tells whether only aggregated statistics of the agency/month can be published.
+// The full data is still kept in the storage, it is just not exposed by the API.
+func isAggregateOnly(agencyName string, month int, year int) bool {
+	m := year*12 + month
+	for _, r := range aggregateOnlyRules {
+		if !strings.EqualFold(canonicalAgencyID(r.agency), agencyName) {
+			continue
+		}
+		if (r.from == 0 || m >= r.from) && (r.to == 0 || m <= r.to) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateSummaries removes from the summaries the statistics that might disclose the income of individuals.
+// Max and Min are always the income of someone, so they are never published. Totals and averages of groups
+// smaller than minGroupSize, as well as histogram buckets with fewer employees than that, are removed too.
+// Removed statistics are published as zero.
+func aggregateSummaries(s storage.Summaries, minGroupSize int) storage.Summaries {
+	return storage.Summaries{
+		General:       aggregateSummary(s.General, minGroupSize),
+		MemberActive:  aggregateSummary(s.MemberActive, minGroupSize),
+		Undefined:     aggregateSummary(s.Undefined, minGroupSize),
+		ServantActive: aggregateSummary(s.ServantActive, minGroupSize),
+	}
+}
+
+func aggregateSummary(s storage.Summary, minGroupSize int) storage.Summary {
+	hist := make(map[int]int)
+	for bucket, count := range s.IncomeHistogram {
+		if count >= minGroupSize {
+			hist[bucket] = count
+		}
+	}
+	s.IncomeHistogram = hist
+	for _, d := range []*storage.DataSummary{&s.Wage, &s.Perks, &s.Others, &s.Benefits} {
+		d.Max, d.Min = 0, 0
+		if s.Count < minGroupSize {
+			d.Average, d.Total = 0, 0
+		}
+	}
+	return s
+}
+
 var conf config
 
 func main() {
@@ -240,12 +951,48 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
+	agencyAliases, err = parseAgencyAliases(conf.AgencyAliases)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	aggregateOnlyRules, err = parseAggregateOnlyRules(conf.AggregateOnlyAgencies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if conf.AggregateMinGroupSize < 1 {
+		log.Fatalf("invalid AGGREGATE_MIN_GROUP_SIZE:%d, it must be at least 1", conf.AggregateMinGroupSize)
+	}
+
+	switch conf.CPFPolicy {
+	case "mask", "none":
+	case "hash":
+		if conf.CPFSalt == "" {
+			log.Fatal("CPF_SALT must be set when CPF_POLICY is hash")
+		}
+	default:
+		log.Fatalf("invalid CPF_POLICY:\"%s\", it must be mask, hash or none", conf.CPFPolicy)
+	}
+
+	if conf.AnnotationsToken != "" && conf.MongoAnCol == "" {
+		log.Fatal("MONGODB_ANCOL must be set when ANNOTATIONS_TOKEN is set")
+	}
+
 	// Criando o client do storage
 	client, err = newClient(conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	mgoClient, err = newMongoClient(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	monthlyInfoCol = mgoClient.Database(conf.MongoDBName).Collection(conf.MongoMICol)
+	if conf.MongoAnCol != "" {
+		annotationsCol = mgoClient.Database(conf.MongoDBName).Collection(conf.MongoAnCol)
+	}
+
 	fmt.Printf("Going to start listening at port:%d\n", conf.Port)
 
 	e := echo.New()
@@ -278,6 +1025,8 @@ func main() {
 	uiAPIGroup.GET("/v1/orgao/salario/:orgao/:ano/:mes", getSalaryOfAgencyMonthYear)
 	// Return the total of salary of every month of a year of a agency. The salary is divided in Wage, Perks and Others. This will be used to plot the bars chart at the state page.
 	uiAPIGroup.GET("/v1/orgao/totais/:orgao/:ano", getTotalsOfAgencyYear)
+	// Return the total of each remuneration component (wage, each perk, each other fund and each discount) of every month of a year of an agency. This will be used to plot the stacked area chart at the agency page.
+	uiAPIGroup.GET("/v1/orgao/componentes/:orgao/:ano", getComponentsOfAgencyYear)
 	// Return basic information of a state
 	uiAPIGroup.GET("/v1/orgao/:estado", getBasicInfoOfState)
 
@@ -289,10 +1038,21 @@ func main() {
 	// Return OMA (órgão/mês/ano) information
 	apiGroup.GET("/v1/orgao/:orgao/:ano/:mes", apiOMA)
 
+	// Maintainers API configuration, authenticated by the "Authorization: Bearer <ANNOTATIONS_TOKEN>" header
+	if conf.AnnotationsToken != "" {
+		adminAPIGroup := e.Group("/adminapi", middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(conf.AnnotationsToken)) == 1, nil
+		}))
+		// Attach an annotation to an agency/month. The body must be a JSON like {"Text": "..."}.
+		adminAPIGroup.POST("/v1/orgao/anotacao/:orgao/:ano/:mes", addAnnotation)
+	}
+
 	s := &http.Server{
 		Addr:         fmt.Sprintf(":%d", conf.Port),
 		ReadTimeout:  5 * time.Minute,
 		WriteTimeout: 5 * time.Minute,
 	}
-	e.Logger.Fatal(e.StartServer(s))
+	err = e.StartServer(s)
+	closeMongoClient()
+	e.Logger.Fatal(err)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dadosjusbr/coletores"
+	"github.com/dadosjusbr/remuneracao-magistrados/models"
+	"github.com/dadosjusbr/storage"
+)
+
+func TestParseAggregateOnlyRules(t *testing.T) {
+	data := []struct {
+		desc    string
+		in      []string
+		want    []aggregateOnlyRule
+		wantErr bool
+	}{
+		{"agency", []string{"trt13"}, []aggregateOnlyRule{{agency: "trt13"}}, false},
+		{"period", []string{"trt13:2019-01:2020-12"}, []aggregateOnlyRule{{agency: "trt13", from: 2019*12 + 1, to: 2020*12 + 12}}, false},
+		{"open end", []string{"trt13:2019-01:"}, []aggregateOnlyRule{{agency: "trt13", from: 2019*12 + 1}}, false},
+		{"open start", []string{"trt13::2020-12"}, []aggregateOnlyRule{{agency: "trt13", to: 2020*12 + 12}}, false},
+		{"single month", []string{"trt13:2019-01:2019-01"}, []aggregateOnlyRule{{agency: "trt13", from: 2019*12 + 1, to: 2019*12 + 1}}, false},
+		{"ends before it starts", []string{"trt13:2020-12:2019-01"}, nil, true},
+		{"missing agency", []string{":2019-01:2020-12"}, nil, true},
+		{"invalid month", []string{"trt13:2019-13:"}, nil, true},
+		{"missing period end", []string{"trt13:2019-01"}, nil, true},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			got, err := parseAggregateOnlyRules(d.in)
+			if (err != nil) != d.wantErr {
+				t.Fatalf("parseAggregateOnlyRules(%q) error = %v, wantErr %v", d.in, err, d.wantErr)
+			}
+			if !reflect.DeepEqual(got, d.want) {
+				t.Errorf("parseAggregateOnlyRules(%q) = %+v, want %+v", d.in, got, d.want)
+			}
+		})
+	}
+}
+
+func TestAggregateSummaries(t *testing.T) {
+	in := storage.Summaries{
+		MemberActive: storage.Summary{
+			Count:           6,
+			Wage:            storage.DataSummary{Max: 30000, Min: 20000, Average: 25000, Total: 150000},
+			IncomeHistogram: map[int]int{10000: 0, 20000: 5, 30000: 1},
+		},
+		ServantActive: storage.Summary{
+			Count:           1,
+			Wage:            storage.DataSummary{Max: 10000, Min: 10000, Average: 10000, Total: 10000},
+			Perks:           storage.DataSummary{Max: 500, Min: 500, Average: 500, Total: 500},
+			IncomeHistogram: map[int]int{10000: 1},
+		},
+	}
+	want := storage.Summaries{
+		General: storage.Summary{IncomeHistogram: map[int]int{}},
+		MemberActive: storage.Summary{
+			Count:           6,
+			Wage:            storage.DataSummary{Average: 25000, Total: 150000},
+			IncomeHistogram: map[int]int{20000: 5},
+		},
+		Undefined:     storage.Summary{IncomeHistogram: map[int]int{}},
+		ServantActive: storage.Summary{Count: 1, IncomeHistogram: map[int]int{}},
+	}
+	if got := aggregateSummaries(in, 5); !reflect.DeepEqual(got, want) {
+		t.Errorf("aggregateSummaries() = %+v, want %+v", got, want)
+	}
+	if in.MemberActive.Wage.Max != 30000 || len(in.MemberActive.IncomeHistogram) != 3 {
+		t.Errorf("aggregateSummaries() changed its input: %+v", in.MemberActive)
+	}
+}
+
+func TestParseAgencyAliases(t *testing.T) {
+	data := []struct {
+		desc    string
+		in      []string
+		want    map[string]agencyAlias
+		wantErr bool
+	}{
+		{"no aliases", nil, map[string]agencyAlias{}, false},
+		{"merger", []string{"trt23:trt13", "trt24:trt13"}, map[string]agencyAlias{"trt23": {canonical: "trt13"}, "trt24": {canonical: "trt13"}}, false},
+		{"rename with month", []string{"tjgo:tj-go:2019-01"}, map[string]agencyAlias{"tjgo": {canonical: "tj-go", until: 2019*12 + 1}}, false},
+		{"chain", []string{"a:b", "b:c"}, map[string]agencyAlias{"a": {canonical: "b"}, "b": {canonical: "c"}}, false},
+		{"cycle", []string{"a:b", "b:c", "c:a"}, nil, true},
+		{"alias to itself", []string{"a:a"}, nil, true},
+		{"two aliases for an ID", []string{"a:b", "a:c"}, nil, true},
+		{"missing new ID", []string{"a:"}, nil, true},
+		{"missing month", []string{"a:b:"}, nil, true},
+		{"invalid month", []string{"a:b:2019"}, nil, true},
+		{"too many parts", []string{"a:b:2019-01:2020-01"}, nil, true},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			got, err := parseAgencyAliases(d.in)
+			if (err != nil) != d.wantErr {
+				t.Fatalf("parseAgencyAliases(%q) error = %v, wantErr %v", d.in, err, d.wantErr)
+			}
+			if !reflect.DeepEqual(got, d.want) {
+				t.Errorf("parseAgencyAliases(%q) = %+v, want %+v", d.in, got, d.want)
+			}
+		})
+	}
+}
+
+func TestValidateAgencyAliases(t *testing.T) {
+	if err := validateAgencyAliases(map[string]agencyAlias{"a": {canonical: "b"}, "b": {canonical: "c"}, "d": {canonical: "c"}}); err != nil {
+		t.Errorf("validateAgencyAliases() of a chain error: %v", err)
+	}
+	if err := validateAgencyAliases(map[string]agencyAlias{"a": {canonical: "b"}, "b": {canonical: "a"}}); err == nil {
+		t.Errorf("validateAgencyAliases() of a cycle returned no error")
+	}
+}
+
+func setAgencyAliases(t *testing.T, entries ...string) {
+	aliases, err := parseAgencyAliases(entries)
+	if err != nil {
+		t.Fatalf("parseAgencyAliases(%q) error: %v", entries, err)
+	}
+	agencyAliases = aliases
+	t.Cleanup(func() { agencyAliases = nil })
+}
+
+func TestCanonicalAgencyID(t *testing.T) {
+	setAgencyAliases(t, "a:b", "b:c:2019-01", "d:c")
+	data := []struct {
+		in, want string
+	}{
+		{"a", "c"},
+		{"b", "c"},
+		{"c", "c"},
+		{"d", "c"},
+		{"unknown", "unknown"},
+	}
+	for _, d := range data {
+		if got := canonicalAgencyID(d.in); got != d.want {
+			t.Errorf("canonicalAgencyID(%q) = %q, want %q", d.in, got, d.want)
+		}
+	}
+}
+
+func TestHistoricalAgencyIDs(t *testing.T) {
+	// a was renamed to b in 2015, b was renamed to c in 2019 and d was merged into c.
+	setAgencyAliases(t, "a:b:2015-01", "b:c:2019-01", "d:c")
+	data := []struct {
+		desc        string
+		id          string
+		month, year int
+		want        []string
+	}{
+		{"before every rename", "c", 12, 2014, []string{"c", "a", "b", "d"}},
+		{"between renames", "c", 1, 2015, []string{"c", "b", "d"}},
+		{"after every rename", "c", 1, 2019, []string{"c", "d"}},
+		{"no aliases", "x", 1, 2019, []string{"x"}},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			if got := historicalAgencyIDs(d.id, d.month, d.year); !reflect.DeepEqual(got, d.want) {
+				t.Errorf("historicalAgencyIDs(%q, %d, %d) = %q, want %q", d.id, d.month, d.year, got, d.want)
+			}
+		})
+	}
+}
+
+func TestValidCPF(t *testing.T) {
+	data := []struct {
+		in   string
+		want bool
+	}{
+		{"52998224725", true},
+		{"11144477735", true},
+		{"52998224726", false},
+		{"12345678900", false},
+		{"00000000000", false},
+		{"11111111111", false},
+	}
+	for _, d := range data {
+		if got := validCPF(d.in); got != d.want {
+			t.Errorf("validCPF(%q) = %v, want %v", d.in, got, d.want)
+		}
+	}
+}
+
+func setCPFPolicy(t *testing.T, policy, salt string) {
+	oldPolicy, oldSalt := conf.CPFPolicy, conf.CPFSalt
+	conf.CPFPolicy, conf.CPFSalt = policy, salt
+	t.Cleanup(func() { conf.CPFPolicy, conf.CPFSalt = oldPolicy, oldSalt })
+}
+
+func TestProtectCPFMask(t *testing.T) {
+	setCPFPolicy(t, "mask", "")
+	data := []struct {
+		desc, in, want string
+	}{
+		{"punctuated", "CPF 529.982.247-25", "CPF ***.***.***-**"},
+		{"punctuated with invalid check digits", "529.982.247-26", "***.***.***-**"},
+		{"partially masked", "***.456.789-**", "***.***.***-**"},
+		{"bare valid", "MARIA 52998224725", "MARIA ***.***.***-**"},
+		{"bare invalid is a matrícula", "12345678900", "12345678900"},
+		{"repeated digits", "11111111111", "11111111111"},
+		{"longer number", "529982247251", "529982247251"},
+		{"json", `{"reg":"52998224725"}`, `{"reg":"***.***.***-**"}`},
+		{"no digits", "***.***.***-**", "***.***.***-**"},
+		{"no CPF", "JUIZ DE DIREITO", "JUIZ DE DIREITO"},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			if got := protectCPF(d.in); got != d.want {
+				t.Errorf("protectCPF(%q) = %q, want %q", d.in, got, d.want)
+			}
+		})
+	}
+}
+
+func TestProtectCPFHash(t *testing.T) {
+	setCPFPolicy(t, "hash", "salt")
+	punctuated, bare := protectCPF("529.982.247-25"), protectCPF("52998224725")
+	if punctuated != bare {
+		t.Errorf("protectCPF() of the same CPF with and without punctuation = %q and %q, want equal hashes", punctuated, bare)
+	}
+	if punctuated == "529.982.247-25" || len(punctuated) != 16 {
+		t.Errorf("protectCPF(\"529.982.247-25\") = %q, want a 16 characters hash", punctuated)
+	}
+	if again := protectCPF("52998224725"); again != bare {
+		t.Errorf("protectCPF() is not deterministic: %q and %q", bare, again)
+	}
+	if other := protectCPF("11144477735"); other == bare {
+		t.Errorf("protectCPF() of different CPFs = %q, want different hashes", other)
+	}
+	conf.CPFSalt = "other salt"
+	if salted := protectCPF("52998224725"); salted == bare {
+		t.Errorf("protectCPF() with different salts = %q, want different hashes", salted)
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func employeeType(t string) *string {
+	return &t
+}
+
+func TestSumComponents(t *testing.T) {
+	employees := []coletores.Employee{
+		// Itemizes part of each group.
+		{Type: employeeType("membro"), Active: true,
+			Income: &coletores.IncomeDetails{Total: 36000.10, Wage: float(30000),
+				Perks: &coletores.Perks{Total: 1500.10, Food: float(1000), Pecuniary: float(300.05)},
+				Other: &coletores.Funds{Total: 4500, PersonalBenefits: float(2000), OtherFundsTotal: float(1000), Others: map[string]float64{"GAE": 1000}}},
+			Discounts: &coletores.Discount{Total: 9000, IncomeTax: float(7000), Others: map[string]float64{"PSS": 500}}},
+		// Only publishes the totals of each group.
+		{Type: employeeType("membro"), Active: true,
+			Income: &coletores.IncomeDetails{Total: 33000, Wage: float(30000),
+				Perks: &coletores.Perks{Total: 2000},
+				Other: &coletores.Funds{Total: 1000}},
+			Discounts: &coletores.Discount{Total: 8000}},
+		// Not active members, which are not in the MemberActive summary.
+		{Type: employeeType("membro"), Active: false, Income: &coletores.IncomeDetails{Total: 1000, Wage: float(1000)}},
+		{Type: employeeType("servidor"), Active: true, Income: &coletores.IncomeDetails{Total: 1000, Wage: float(1000)}},
+		{Active: true, Income: &coletores.IncomeDetails{Total: 1000, Wage: float(1000)}},
+	}
+	// The MemberActive summary of the employees above.
+	memberActive := storage.Summary{
+		Count:  2,
+		Wage:   storage.DataSummary{Total: 60000},
+		Perks:  storage.DataSummary{Total: 3500.10},
+		Others: storage.DataSummary{Total: 5500},
+	}
+	mc := sumComponents(3, employees)
+
+	if mc.Month != 3 {
+		t.Errorf("sumComponents() month = %d, want 3", mc.Month)
+	}
+	if want := models.NewMoney(memberActive.Wage.Total); mc.Wage != want {
+		t.Errorf("sumComponents() wage = %s, want %s", mc.Wage, want)
+	}
+	if got, want := sumValues(mc.Perks), models.NewMoney(memberActive.Perks.Total); got != want {
+		t.Errorf("sumComponents() perks sum up to %s, want %s", got, want)
+	}
+	if got, want := sumValues(mc.Others), models.NewMoney(memberActive.Others.Total); got != want {
+		t.Errorf("sumComponents() others sum up to %s, want %s", got, want)
+	}
+	if got, want := sumValues(mc.Discounts), models.NewMoney(17000); got != want {
+		t.Errorf("sumComponents() discounts sum up to %s, want %s", got, want)
+	}
+
+	wantPerks := map[string]models.Money{"food": 100000, "pecuniary": 30005, "unclassified": 220005}
+	if !reflect.DeepEqual(mc.Perks, wantPerks) {
+		t.Errorf("sumComponents() perks = %v, want %v", mc.Perks, wantPerks)
+	}
+	wantOthers := map[string]models.Money{"personal_benefits": 200000, "others_total": 100000, "unclassified": 250000}
+	if !reflect.DeepEqual(mc.Others, wantOthers) {
+		t.Errorf("sumComponents() others = %v, want %v", mc.Others, wantOthers)
+	}
+	wantDiscounts := map[string]models.Money{"income_tax": 700000, "others_total": 50000, "unclassified": 950000}
+	if !reflect.DeepEqual(mc.Discounts, wantDiscounts) {
+		t.Errorf("sumComponents() discounts = %v, want %v", mc.Discounts, wantDiscounts)
+	}
+	if want := map[string]models.Money{"GAE": 100000}; !reflect.DeepEqual(mc.PublishedOthers, want) {
+		t.Errorf("sumComponents() published others = %v, want %v", mc.PublishedOthers, want)
+	}
+	if want := map[string]models.Money{"PSS": 50000}; !reflect.DeepEqual(mc.PublishedDiscounts, want) {
+		t.Errorf("sumComponents() published discounts = %v, want %v", mc.PublishedDiscounts, want)
+	}
+}
+
+func TestSumIncomes(t *testing.T) {
+	employees := []coletores.Employee{
+		{Type: employeeType("membro"), Active: true, Income: &coletores.IncomeDetails{Total: 30000}},
+		{Type: employeeType("membro"), Active: false, Income: &coletores.IncomeDetails{Total: 20000}},
+		{Type: employeeType("servidor"), Active: false, Income: &coletores.IncomeDetails{Total: 5000.50}},
+		{Type: employeeType("pensionista"), Active: true, Income: &coletores.IncomeDetails{Total: 8000}},
+		{Type: employeeType("pensionista"), Active: false, Income: &coletores.IncomeDetails{Total: 7000.25}},
+		{Type: employeeType("pensionista"), Active: false},
+	}
+	data := []struct {
+		desc      string
+		match     func(coletores.Employee) bool
+		wantCount int
+		wantTotal models.Money
+	}{
+		{"inactives", isInactive, 2, 2500050},
+		{"pensioners, active or not", isPensioner, 3, 1500025},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			count, total := sumIncomes(employees, d.match)
+			if count != d.wantCount || total != d.wantTotal {
+				t.Errorf("sumIncomes() = %d, %s, want %d, %s", count, total, d.wantCount, d.wantTotal)
+			}
+		})
+	}
+}
@@ -8,74 +8,170 @@ import (
 
 // State - Struct cotains information of a state ans its agencies
 type State struct {
-	Name      string
-	ShortName string
-	FlagURL   string
-	Agency    []AgencyBasic
+	Name      string        `json:"Name" bson:"Name"`
+	ShortName string        `json:"ShortName" bson:"ShortName"`
+	FlagURL   string        `json:"FlagURL" bson:"FlagURL"`
+	Agency    []AgencyBasic `json:"Agency" bson:"Agency"`
 }
 
 // AgencyBasic - Basic information of a agency (name e category)
 type AgencyBasic struct {
-	Name           string
-	FullName       string
-	AgencyCategory string
+	Name           string `json:"Name" bson:"Name"`
+	FullName       string `json:"FullName" bson:"FullName"`
+	AgencyCategory string `json:"AgencyCategory" bson:"AgencyCategory"`
 }
 
-// Employee - Represents an employee and his/her salary info
+// Employee - Represents an employee and his/her salary info.
+// Collected data is stored as coletores.Employee, whose organization and keys are different, so this model does not decode it.
 type Employee struct {
-	Name   string
-	Wage   float64
-	Perks  float64
-	Others float64
-	Total  float64
-	Type   string
-	Active bool
+	Name           string        `json:"Name" bson:"Name"`
+	Role           string        `json:"Role" bson:"Role"`                     // Cargo
+	Workplace      string        `json:"Workplace" bson:"Workplace"`           // Lotação, like '10° Zona eleitoral'
+	RegistrationID string        `json:"RegistrationID" bson:"RegistrationID"` // Matrícula
+	Income         IncomeDetails `json:"Income" bson:"Income"`
+	Discounts      Discounts     `json:"Discounts" bson:"Discounts"`
+	Type           string        `json:"Type" bson:"Type"` // membro, servidor, pensionista or indefinido, like coletores.Employee.Type
+	Active         bool          `json:"Active" bson:"Active"`
+}
+
+// NetIncome returns the income of the employee after all discounts
+func (e Employee) NetIncome() Money {
+	return e.Income.Total - e.Discounts.Total
+}
+
+// IncomeDetails - Gross income of an employee, organized like the columns of the CNJ resolutions anexos.
+// Like in coletores, itemized amounts are pointers, so an item the agency does not publish is nil and not R$ 0,00.
+type IncomeDetails struct {
+	Total             Money             `json:"Total" bson:"Total"`
+	BaseWage          *Money            `json:"BaseWage,omitempty" bson:"BaseWage,omitempty"` // Subsídio or remuneração do cargo efetivo
+	PermanentBenefits PermanentBenefits `json:"PermanentBenefits" bson:"PermanentBenefits"`   // Vantagens pessoais
+	EventualBenefits  EventualBenefits  `json:"EventualBenefits" bson:"EventualBenefits"`     // Vantagens eventuais
+	Perks             Perks             `json:"Perks" bson:"Perks"`                           // Indenizações
+}
+
+// PermanentBenefits - Benefits paid every month on top of the base wage
+type PermanentBenefits struct {
+	Total            Money            `json:"Total" bson:"Total"`
+	PersonalBenefits *Money           `json:"PersonalBenefits,omitempty" bson:"PersonalBenefits,omitempty"` // Adicional por tempo de serviço, quintos, VPI, etc
+	PositionOfTrust  *Money           `json:"PositionOfTrust,omitempty" bson:"PositionOfTrust,omitempty"`   // Função de confiança or cargo em comissão
+	Others           map[string]Money `json:"Others,omitempty" bson:"Others,omitempty"`                     // Benefits not classified above, keyed by the name published by the agency
+}
+
+// EventualBenefits - Benefits paid only in some months
+type EventualBenefits struct {
+	Total          Money            `json:"Total" bson:"Total"`
+	VacationBonus  *Money           `json:"VacationBonus,omitempty" bson:"VacationBonus,omitempty"`   // Abono constitucional de 1/3 de férias
+	ChristmasBonus *Money           `json:"ChristmasBonus,omitempty" bson:"ChristmasBonus,omitempty"` // Gratificação natalina (13º salário)
+	Overtime       *Money           `json:"Overtime,omitempty" bson:"Overtime,omitempty"`             // Serviço extraordinário
+	Substitution   *Money           `json:"Substitution,omitempty" bson:"Substitution,omitempty"`     // Substituição
+	Retroactive    *Money           `json:"Retroactive,omitempty" bson:"Retroactive,omitempty"`       // Pagamentos retroativos
+	Others         map[string]Money `json:"Others,omitempty" bson:"Others,omitempty"`                 // Benefits not classified above, keyed by the name published by the agency
+}
+
+// Perks - Itemized perks (indenizações) of an employee
+type Perks struct {
+	Total                   Money            `json:"Total" bson:"Total"`
+	Food                    *Money           `json:"Food,omitempty" bson:"Food,omitempty"`                                       // Auxílio-alimentação
+	Health                  *Money           `json:"Health,omitempty" bson:"Health,omitempty"`                                   // Auxílio-saúde
+	HousingAid              *Money           `json:"HousingAid,omitempty" bson:"HousingAid,omitempty"`                           // Auxílio-moradia
+	BirthAid                *Money           `json:"BirthAid,omitempty" bson:"BirthAid,omitempty"`                               // Auxílio-natalidade
+	PreSchool               *Money           `json:"PreSchool,omitempty" bson:"PreSchool,omitempty"`                             // Auxílio pré-escolar
+	Transportation          *Money           `json:"Transportation,omitempty" bson:"Transportation,omitempty"`                   // Auxílio-transporte
+	Vacations               *Money           `json:"Vacations,omitempty" bson:"Vacations,omitempty"`                             // Férias indenizadas
+	Subsistence             *Money           `json:"Subsistence,omitempty" bson:"Subsistence,omitempty"`                         // Ajuda de custo
+	CompensatoryLeave       *Money           `json:"CompensatoryLeave,omitempty" bson:"CompensatoryLeave,omitempty"`             // Licença compensatória
+	Pecuniary               *Money           `json:"Pecuniary,omitempty" bson:"Pecuniary,omitempty"`                             // Pecúnia
+	VacationPecuniary       *Money           `json:"VacationPecuniary,omitempty" bson:"VacationPecuniary,omitempty"`             // Pecúnia de férias
+	FurnitureTransport      *Money           `json:"FurnitureTransport,omitempty" bson:"FurnitureTransport,omitempty"`           // Transporte mobiliário
+	PremiumLicensePecuniary *Money           `json:"PremiumLicensePecuniary,omitempty" bson:"PremiumLicensePecuniary,omitempty"` // Licença-prêmio convertida em pecúnia
+	Others                  map[string]Money `json:"Others,omitempty" bson:"Others,omitempty"`                                   // Perks not classified above, keyed by the name published by the agency
+}
+
+// Discounts - Mandatory deductions applied to an employee's income
+type Discounts struct {
+	Total            Money            `json:"Total" bson:"Total"`
+	PrevContribution *Money           `json:"PrevContribution,omitempty" bson:"PrevContribution,omitempty"` // Contribuição previdenciária
+	IncomeTax        *Money           `json:"IncomeTax,omitempty" bson:"IncomeTax,omitempty"`               // Imposto de renda
+	CeilRetention    *Money           `json:"CeilRetention,omitempty" bson:"CeilRetention,omitempty"`       // Retenção por teto constitucional
+	Others           map[string]Money `json:"Others,omitempty" bson:"Others,omitempty"`                     // Discounts not classified above, keyed by the name published by the agency
 }
 
 // AgencySummary - Summary of an agency
 type AgencySummary struct {
-	FullName          string
-	TotalEmployees    int
-	TotalWage         float64
-	TotalPerks        float64
-	MaxWage           float64
-	CrawlingTime      time.Time
-	AgencyName        string
-	TotalMembers      int
-	TotalServants     int
-	TotalInactives    int
-	MaxPerk           float64
-	TotalRemuneration float64
-	HasNext           bool
-	HasPrevious       bool
+	FullName                   string       `json:"FullName" bson:"FullName"`
+	TotalEmployees             int          `json:"TotalEmployees" bson:"TotalEmployees"`
+	TotalWage                  Money        `json:"TotalWage" bson:"TotalWage"`
+	TotalPerks                 Money        `json:"TotalPerks" bson:"TotalPerks"`
+	MaxWage                    Money        `json:"MaxWage" bson:"MaxWage"`
+	CrawlingTime               time.Time    `json:"CrawlingTime" bson:"CrawlingTime"`
+	AgencyName                 string       `json:"AgencyName" bson:"AgencyName"`
+	TotalMembers               int          `json:"TotalMembers" bson:"TotalMembers"`
+	TotalServants              int          `json:"TotalServants" bson:"TotalServants"`
+	TotalInactives             int          `json:"TotalInactives" bson:"TotalInactives"`
+	MaxPerk                    Money        `json:"MaxPerk" bson:"MaxPerk"`
+	TotalRemuneration          Money        `json:"TotalRemuneration" bson:"TotalRemuneration"`
+	TotalInactiveRemuneration  Money        `json:"TotalInactiveRemuneration" bson:"TotalInactiveRemuneration"`
+	TotalPensioners            int          `json:"TotalPensioners" bson:"TotalPensioners"`
+	TotalPensionerRemuneration Money        `json:"TotalPensionerRemuneration" bson:"TotalPensionerRemuneration"`
+	HasNext                    bool         `json:"HasNext" bson:"HasNext"`
+	HasPrevious                bool         `json:"HasPrevious" bson:"HasPrevious"`
+	Annotations                []Annotation `json:"Annotations" bson:"Annotations"`
+}
+
+// Annotation - Free-text note attached by maintainers to an agency/month, so known caveats travel with the data
+type Annotation struct {
+	AgencyID  string    `json:"AgencyID" bson:"aid"`
+	Year      int       `json:"Year" bson:"year"`
+	Month     int       `json:"Month" bson:"month"`
+	Text      string    `json:"Text" bson:"text"`
+	Timestamp time.Time `json:"Timestamp" bson:"ts"`
 }
 
 // AgencyTotalsYear - Represents the totals of an year
 type AgencyTotalsYear struct {
-	Year           int
-	MonthTotals    []MonthTotals
-	AgencyFullName string
+	Year           int           `json:"Year" bson:"Year"`
+	MonthTotals    []MonthTotals `json:"MonthTotals" bson:"MonthTotals"`
+	AgencyFullName string        `json:"AgencyFullName" bson:"AgencyFullName"`
 }
 
 // MonthTotals - Detailed info of a month (wage, perks, other)
 type MonthTotals struct {
-	Month  int
-	Wage   float64
-	Perks  float64
-	Others float64
+	Month  int   `json:"Month" bson:"Month"`
+	Wage   Money `json:"Wage" bson:"Wage"`
+	Perks  Money `json:"Perks" bson:"Perks"`
+	Others Money `json:"Others" bson:"Others"`
+}
+
+// AgencyComponentsYear - Represents the totals of each remuneration component in every month of an year
+type AgencyComponentsYear struct {
+	Year            int               `json:"Year" bson:"Year"`
+	MonthComponents []MonthComponents `json:"MonthComponents" bson:"MonthComponents"`
+	AgencyFullName  string            `json:"AgencyFullName" bson:"AgencyFullName"`
+}
+
+// MonthComponents - Totals of each remuneration component of a month (wage, each perk, each other fund and each discount).
+// The part of the total of a group which agencies do not itemize is under "unclassified", so each group adds up to its total.
+type MonthComponents struct {
+	Month              int              `json:"Month" bson:"Month"`
+	Wage               Money            `json:"Wage" bson:"Wage"`
+	Perks              map[string]Money `json:"Perks" bson:"Perks"`
+	Others             map[string]Money `json:"Others" bson:"Others"`
+	PublishedOthers    map[string]Money `json:"PublishedOthers" bson:"PublishedOthers"` // Items of Others["others_total"], keyed by the names published by the agencies. Only covers agencies which itemize them.
+	Discounts          map[string]Money `json:"Discounts" bson:"Discounts"`
+	PublishedDiscounts map[string]Money `json:"PublishedDiscounts" bson:"PublishedDiscounts"` // Items of Discounts["others_total"], keyed by the names published by the agencies. Only covers agencies which itemize them.
 }
 
 // DataForChartAtAgencyScreen - contains all necessary data to load chart
 type DataForChartAtAgencyScreen struct {
-	Members     map[int]int
-	Servers     map[int]int
-	MaxSalary   float64
-	PackageURL  string
-	PackageHash string
+	Members     map[int]int `json:"Members" bson:"Members"`
+	Servers     map[int]int `json:"Servers" bson:"Servers"`
+	MaxSalary   Money       `json:"MaxSalary" bson:"MaxSalary"`
+	PackageURL  string      `json:"PackageURL" bson:"PackageURL"`
+	PackageHash string      `json:"PackageHash" bson:"PackageHash"`
 }
 
 // ProcInfoResult - contains information of the result of the process if something went wrong during parsing or crawling process
 type ProcInfoResult struct {
-	ProcInfo          *coletores.ProcInfo
-	CrawlingTimestamp time.Time
+	ProcInfo          *coletores.ProcInfo `json:"ProcInfo" bson:"ProcInfo"`
+	CrawlingTimestamp time.Time           `json:"CrawlingTimestamp" bson:"CrawlingTimestamp"`
 }
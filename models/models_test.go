@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestUnpublishedItemsAreOmitted(t *testing.T) {
+	food := Money(100050)
+	e := Employee{}
+	e.Income.Perks.Food = &food
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("json.Marshal error: %v", err)
+	}
+	if !strings.Contains(string(b), `"Food":1000.50`) {
+		t.Errorf("json.Marshal() = %s, want the published food perk", b)
+	}
+	if strings.Contains(string(b), `"Health"`) || strings.Contains(string(b), `"IncomeTax"`) {
+		t.Errorf("json.Marshal() = %s, want no unpublished items", b)
+	}
+
+	b, err = bson.Marshal(e)
+	if err != nil {
+		t.Fatalf("bson.Marshal error: %v", err)
+	}
+	var got Employee
+	if err := bson.Unmarshal(b, &got); err != nil {
+		t.Fatalf("bson.Unmarshal error: %v", err)
+	}
+	if got.Income.Perks.Food == nil || *got.Income.Perks.Food != food {
+		t.Errorf("bson round trip food = %v, want %d", got.Income.Perks.Food, food)
+	}
+	if got.Income.Perks.Health != nil || got.Discounts.IncomeTax != nil {
+		t.Errorf("bson round trip = %+v, want unpublished items to stay nil", got)
+	}
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Money - Amount of money in cents. Using integers avoids the rounding errors accumulated when summing thousands of float64 values
+type Money int64
+
+// NewMoney converts a decimal amount (e.g. 1234.56) to Money, rounding it to the nearest cent.
+func NewMoney(v float64) Money {
+	return Money(math.Round(v * 100))
+}
+
+// Float64 returns the decimal amount (e.g. 1234.56).
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String formats the amount as Brazilian currency (e.g. "R$ 1.234,56").
+func (m Money) String() string {
+	sign, units, cents := m.parts()
+	digits := strconv.FormatUint(units, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, b.String(), cents)
+}
+
+// MarshalJSON encodes the amount as a decimal number, so the API keeps publishing reais, not cents.
+func (m Money) MarshalJSON() ([]byte, error) {
+	sign, units, cents := m.parts()
+	return []byte(fmt.Sprintf("%s%d.%02d", sign, units, cents)), nil
+}
+
+// UnmarshalJSON decodes a decimal number (or a string accepted by ParseMoney) into cents.
+// Numbers are always decimal, so "1.234" is 1,23 and not 1.234,00 like in ParseMoney.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("error decoding money from json: %q", err)
+		}
+		v, err := ParseMoney(s)
+		if err != nil {
+			return fmt.Errorf("error decoding money from json: %q", err)
+		}
+		*m = v
+		return nil
+	}
+	// Amounts coming from float64 fields may have more than two decimal places, they are rounded to cents.
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("error decoding money from json: %q", err)
+	}
+	*m = NewMoney(f)
+	return nil
+}
+
+// MarshalBSONValue stores the amount as a decimal double, like the float64 amounts already in the storage.
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(m.Float64())
+}
+
+// UnmarshalBSONValue decodes a decimal double (or an integer amount of reais) into cents.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	rv := bson.RawValue{Type: t, Value: data}
+	if f, ok := rv.DoubleOK(); ok {
+		*m = NewMoney(f)
+		return nil
+	}
+	if i, ok := rv.Int32OK(); ok {
+		*m = Money(i) * 100
+		return nil
+	}
+	if i, ok := rv.Int64OK(); ok {
+		*m = Money(i) * 100
+		return nil
+	}
+	if t == bsontype.Null {
+		return nil
+	}
+	return fmt.Errorf("error decoding money from bson: unexpected type %s", t)
+}
+
+func (m Money) parts() (string, uint64, uint64) {
+	sign := ""
+	abs := uint64(m)
+	if m < 0 {
+		sign = "-"
+		abs = uint64(-m)
+	}
+	return sign, abs / 100, abs % 100
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMoneyString(t *testing.T) {
+	data := []struct {
+		in   Money
+		want string
+	}{
+		{0, "R$ 0,00"},
+		{5, "R$ 0,05"},
+		{123456, "R$ 1.234,56"},
+		{123456789, "R$ 1.234.567,89"},
+		{-7, "-R$ 0,07"},
+		{-100000, "-R$ 1.000,00"},
+	}
+	for _, d := range data {
+		if got := d.in.String(); got != d.want {
+			t.Errorf("Money(%d).String() = %q, want %q", d.in, got, d.want)
+		}
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	data := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{123456, "1234.56"},
+		{-123450, "-1234.50"},
+		{-7, "-0.07"},
+	}
+	for _, d := range data {
+		got, err := json.Marshal(d.in)
+		if err != nil {
+			t.Fatalf("json.Marshal(Money(%d)) error: %v", d.in, err)
+		}
+		if string(got) != d.want {
+			t.Errorf("json.Marshal(Money(%d)) = %s, want %s", d.in, got, d.want)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	data := []struct {
+		desc    string
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"decimal", "1234.56", 123456, false},
+		{"negative", "-1234.5", -123450, false},
+		{"rounds to cents", "1234.5678", 123457, false},
+		{"three decimal places are not thousands", "1.234", 123, false},
+		{"three decimal places are rounded as floats", "1.005", 100, false},
+		{"exponent", "1e3", 100000, false},
+		{"string", `"R$ 1,00"`, 100, false},
+		{"string with thousands separator", `"1.234"`, 123400, false},
+		{"invalid string", `"abc"`, 42, true},
+		{"null keeps the value", "null", 42, false},
+		{"invalid", "true", 42, true},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			got := Money(42)
+			err := json.Unmarshal([]byte(d.in), &got)
+			if (err != nil) != d.wantErr {
+				t.Fatalf("json.Unmarshal(%s) error = %v, wantErr %v", d.in, err, d.wantErr)
+			}
+			if got != d.want {
+				t.Errorf("json.Unmarshal(%s) = %d, want %d", d.in, got, d.want)
+			}
+		})
+	}
+}
+
+func TestMoneyBSON(t *testing.T) {
+	type doc struct {
+		V Money `bson:"v"`
+	}
+	b, err := bson.Marshal(doc{V: -123456})
+	if err != nil {
+		t.Fatalf("bson.Marshal error: %v", err)
+	}
+	var raw struct {
+		V float64 `bson:"v"`
+	}
+	if err := bson.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("bson.Unmarshal error: %v", err)
+	}
+	if raw.V != -1234.56 {
+		t.Errorf("stored value = %v, want -1234.56", raw.V)
+	}
+	var got doc
+	if err := bson.Unmarshal(b, &got); err != nil {
+		t.Fatalf("bson.Unmarshal error: %v", err)
+	}
+	if got.V != -123456 {
+		t.Errorf("bson round trip = %d, want -123456", got.V)
+	}
+
+	b, err = bson.Marshal(struct {
+		V int32 `bson:"v"`
+	}{V: 15})
+	if err != nil {
+		t.Fatalf("bson.Marshal error: %v", err)
+	}
+	if err := bson.Unmarshal(b, &got); err != nil {
+		t.Fatalf("bson.Unmarshal error: %v", err)
+	}
+	if got.V != 1500 {
+		t.Errorf("bson int32 = %d, want 1500", got.V)
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseMoney parses amounts like "1234.56", "1.234,56", "R$ -1.234,56" and "(1.234,56)", which is negative.
+// "-" (or "R$ -") is how agencies publish empty values, so it is parsed as zero.
+// When there is no comma, dots separating groups of three digits (e.g. "1.234") are thousands separators,
+// otherwise the dot is the decimal separator.
+func ParseMoney(s string) (Money, error) {
+	v := strings.TrimSpace(s)
+	if v == "" {
+		return 0, fmt.Errorf("invalid amount: it is empty")
+	}
+	neg := false
+	if strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		neg = true
+		v = strings.TrimSpace(v[1 : len(v)-1])
+	}
+	v = strings.TrimSpace(strings.TrimPrefix(v, "R$"))
+	if v == "-" && !neg {
+		return 0, nil
+	}
+	if strings.HasPrefix(v, "-") {
+		if neg {
+			return 0, fmt.Errorf("invalid amount \"%s\": it has two negative signs", s)
+		}
+		neg = true
+		v = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(v, "-")), "R$"))
+	}
+	if v == "" {
+		return 0, fmt.Errorf("invalid amount \"%s\": it has no digits", s)
+	}
+	switch {
+	case strings.Contains(v, ","):
+		v = strings.Replace(strings.ReplaceAll(v, ".", ""), ",", ".", 1)
+	case isThousandsGrouped(v):
+		v = strings.ReplaceAll(v, ".", "")
+	}
+	units, cents := v, "00"
+	if i := strings.Index(v, "."); i >= 0 {
+		units, cents = v[:i], v[i+1:]
+	}
+	if !isDigits(units) || !isDigits(cents) || (units == "" && cents == "") {
+		return 0, fmt.Errorf("invalid amount \"%s\"", s)
+	}
+	switch len(cents) {
+	case 0:
+		return 0, fmt.Errorf("invalid amount \"%s\"", s)
+	case 1:
+		cents += "0"
+	case 2:
+	default:
+		return 0, fmt.Errorf("invalid amount \"%s\": it must have up to two decimal places", s)
+	}
+	if units == "" {
+		units = "0"
+	}
+	u, err := strconv.ParseUint(units, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount \"%s\": %q", s, err)
+	}
+	c, _ := strconv.ParseUint(cents, 10, 64)
+	if u > (math.MaxInt64-c)/100 {
+		return 0, fmt.Errorf("invalid amount \"%s\": it is too large", s)
+	}
+	m := Money(u*100 + c)
+	if neg {
+		m = -m
+	}
+	return m, nil
+}
+
+// isThousandsGrouped tells whether the dots of v only split it into groups of three digits, like in "1.234.567".
+func isThousandsGrouped(v string) bool {
+	groups := strings.Split(v, ".")
+	if len(groups) < 2 || len(groups[0]) == 0 || len(groups[0]) > 3 || groups[0][0] == '0' {
+		return false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
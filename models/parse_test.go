@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	data := []struct {
+		desc    string
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{"decimal point", "1234.56", 123456, false},
+		{"brazilian format", "1.234,56", 123456, false},
+		{"currency symbol", "R$ 1.234,56", 123456, false},
+		{"negative after symbol", "R$ -1.234,56", -123456, false},
+		{"negative before symbol", "-R$ 0,07", -7, false},
+		{"parentheses are negative", "(1,00)", -100, false},
+		{"parentheses with symbol", "(R$ 1.234,56)", -123456, false},
+		{"thousands separator only", "1.234", 123400, false},
+		{"many thousands separators", "1.234.567", 123456700, false},
+		{"one decimal place with point", "1.5", 150, false},
+		{"one decimal place with comma", "1,5", 150, false},
+		{"no decimal places", "12", 1200, false},
+		{"no units", ",5", 50, false},
+		{"dash is empty", "-", 0, false},
+		{"dash with symbol is empty", "R$ -", 0, false},
+		{"spaces", "  R$ 10,00  ", 1000, false},
+		{"largest amount", "92233720368547758.07", 9223372036854775807, false},
+		{"empty", "", 0, true},
+		{"not a number", "abc", 0, true},
+		{"three decimal places with comma", "1,234", 0, true},
+		{"three decimal places with point", "1234.567", 0, true},
+		{"two negative signs", "(-1,00)", 0, true},
+		{"two commas", "1,2,3", 0, true},
+		{"only separator", ",", 0, true},
+		{"only symbol", "R$", 0, true},
+		{"only symbol in parentheses", "(R$)", 0, true},
+		{"only symbol and negative sign", "-R$", 0, true},
+		{"empty parentheses", "()", 0, true},
+		{"overflow", "92233720368547758.08", 0, true},
+	}
+	for _, d := range data {
+		t.Run(d.desc, func(t *testing.T) {
+			got, err := ParseMoney(d.in)
+			if (err != nil) != d.wantErr {
+				t.Fatalf("ParseMoney(%q) error = %v, wantErr %v", d.in, err, d.wantErr)
+			}
+			if got != d.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", d.in, got, d.want)
+			}
+		})
+	}
+}